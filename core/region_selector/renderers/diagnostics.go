@@ -0,0 +1,21 @@
+package renderers
+
+import (
+	"fmt"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// installGLFWErrorCallback routes GLFW errors (context creation failures, missing
+// extensions, lost X11 connections, ...) to Sentry, tagged with the GLFW error code and
+// description. It only reports them: a window creation failure in Init still panics.
+func installGLFWErrorCallback() {
+	glfw.SetErrorCallback(func(code glfw.ErrorCode, description string) {
+		sentry.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("glfw_error_code", fmt.Sprintf("%d", code))
+			scope.SetTag("glfw_error_description", description)
+			sentry.CaptureException(fmt.Errorf("glfw error %d: %s", code, description))
+		})
+	})
+}
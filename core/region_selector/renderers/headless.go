@@ -0,0 +1,157 @@
+package renderers
+
+import "image"
+
+// headlessRenderer is the last-resort fallback for machines with no display server at all
+// (CI runners, SSH-only boxes, containers without an X11/Wayland/Cocoa socket). Unlike
+// openGLRenderer and softwareRenderer, it never touches GLFW or GL - RendererInit always
+// succeeds, at the cost of never actually putting anything on screen. It exists so a
+// headless MagicCap can still capture and crop a region (e.g. driven by a CLI flag rather
+// than a mouse drag) without the whole selector flow panicking on a GLFW init failure.
+type headlessRenderer struct {
+	displays       []image.Rectangle
+	screenshots    []*image.RGBA
+	contentScaleX  []float32
+	contentScaleY  []float32
+	mousePressCb   func(index int, pos image.Rectangle)
+	mouseReleaseCb func(index int, pos image.Rectangle)
+	keyCb          func(Release bool, Index, Key int)
+
+	displaysChangedCb func(newDisplays []image.Rectangle)
+	eventMode         EventMode
+	closed            bool
+}
+
+// Init is used to initialise the renderer. No windows are created - the screenshots are
+// just kept in memory for GetNormalTexturePixels/GetDimmedTexture to read back from.
+func (r *headlessRenderer) Init(Displays []image.Rectangle, Screenshots []*image.RGBA) {
+	r.displays = Displays
+	r.screenshots = Screenshots
+	r.contentScaleX = make([]float32, len(Displays))
+	r.contentScaleY = make([]float32, len(Displays))
+	for i := range Displays {
+		// There is no real window to derive a framebuffer/logical ratio from, so assume
+		// standard DPI.
+		r.contentScaleX[i] = 1
+		r.contentScaleY[i] = 1
+	}
+}
+
+// GetContentScale is used to get the content (DPI) scale of a display. See the matching
+// doc comment on openGLRenderer.GetContentScale.
+func (r *headlessRenderer) GetContentScale(index int) (float32, float32) {
+	if index < 0 || index >= len(r.contentScaleX) {
+		return 1, 1
+	}
+	return r.contentScaleX[index], r.contentScaleY[index]
+}
+
+// RenderTexture is a no-op - there is no screen to draw to.
+func (r *headlessRenderer) RenderTexture(index int, t Texture) {}
+
+// headlessTexture wraps the in-memory screenshot for a single display. There is no GPU
+// texture behind it, so dimming is tracked but never actually applied to pixels - nothing
+// ever reads it back through this renderer's draw path.
+type headlessTexture struct {
+	img *image.RGBA
+	dim float32
+}
+
+// Begin is a no-op - there is no GL context to bind into.
+func (t *headlessTexture) Begin() {}
+
+// End is a no-op - there is no GL context to unbind.
+func (t *headlessTexture) End() {}
+
+// SetPixels writes pixels directly into the backing image.
+func (t *headlessTexture) SetPixels(X, Y, Width, Height int, Pix []byte) {
+	for y := 0; y < Height; y++ {
+		srcOff := y * Width * 4
+		dstOff := t.img.PixOffset(X, Y+y)
+		copy(t.img.Pix[dstOff:dstOff+Width*4], Pix[srcOff:srcOff+Width*4])
+	}
+}
+
+// GetWidthHeight is used to get the width/height.
+func (t *headlessTexture) GetWidthHeight() (int, int) {
+	b := t.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// GetDimmedTexture is used to get a handle to the screenshot texture that draws dimmed by
+// the given factor. blurRadius is accepted for interface parity with openGLRenderer but
+// ignored - nothing ever draws a headlessTexture to a screen. Returns nil if index belongs
+// to a display that isn't known.
+func (r *headlessRenderer) GetDimmedTexture(index int, dim, blurRadius float32) Texture {
+	if index < 0 || index >= len(r.screenshots) {
+		return nil
+	}
+	return &headlessTexture{img: r.screenshots[index], dim: dim}
+}
+
+// GetNormalTexturePixels is used to get the normal texture pixels.
+func (r *headlessRenderer) GetNormalTexturePixels(index, Left, Top, W, H int) []uint8 {
+	if index < 0 || index >= len(r.screenshots) {
+		return nil
+	}
+	img := r.screenshots[index]
+	out := make([]uint8, W*H*4)
+	for y := 0; y < H; y++ {
+		srcOff := img.PixOffset(Left, Top+y)
+		copy(out[y*W*4:(y+1)*W*4], img.Pix[srcOff:srcOff+W*4])
+	}
+	return out
+}
+
+// PollEvents is a no-op - there is no event queue to drain without a display server.
+func (r *headlessRenderer) PollEvents() {}
+
+// SetEventMode records the mode but has no effect - there is no event loop for it to affect.
+func (r *headlessRenderer) SetEventMode(mode EventMode) {
+	r.eventMode = mode
+}
+
+// WakeEventLoop is a no-op - PollEvents never blocks here.
+func (r *headlessRenderer) WakeEventLoop() {}
+
+// SetKeyCallback is used to handle key callbacks.
+func (r *headlessRenderer) SetKeyCallback(Function func(Release bool, index, key int)) {
+	r.keyCb = Function
+}
+
+// SetMousePressCallback is used to set a mouse callback for when it is pressed.
+func (r *headlessRenderer) SetMousePressCallback(Function func(index int, pos image.Rectangle)) {
+	r.mousePressCb = Function
+}
+
+// SetMouseReleaseCallback is used to set a mouse callback for when it is released.
+func (r *headlessRenderer) SetMouseReleaseCallback(Function func(index int, pos image.Rectangle)) {
+	r.mouseReleaseCb = Function
+}
+
+// SetDisplaysChangedCallback registers a callback invoked whenever a monitor is plugged,
+// unplugged, or changes resolution mid-session. headlessRenderer never observes any of
+// these - it has no GLFW monitor callback to hang it off - so Function is stored but never
+// called.
+func (r *headlessRenderer) SetDisplaysChangedCallback(Function func(newDisplays []image.Rectangle)) {
+	r.displaysChangedCb = Function
+}
+
+// DestroyAll is a no-op - there are no windows or GL resources to release.
+func (r *headlessRenderer) DestroyAll() {}
+
+// ShouldClose marks the (non-existent) windows as closed.
+func (r *headlessRenderer) ShouldClose() {
+	r.closed = true
+}
+
+// WindowShouldClose is used to check if a window should close.
+func (r *headlessRenderer) WindowShouldClose(index int) bool {
+	return r.closed
+}
+
+// RendererInit is used to initialise the renderer. It never fails - headlessRenderer is
+// the bottom of the fallback chain and doesn't depend on GLFW, GL, or a display server.
+func (headlessRenderer) RendererInit() error {
+	return nil
+}
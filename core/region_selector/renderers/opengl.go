@@ -2,6 +2,7 @@ package renderers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/MagicCap/glhf"
 	"github.com/getsentry/sentry-go"
 	"github.com/go-gl/gl/v3.3-core/gl"
@@ -11,32 +12,65 @@ import (
 	"runtime"
 )
 
-// This is used to define a RGBA image.
-type rgbaImage struct {
-	data []byte
-	w, h int
-}
-
 // This is used to define the OpenGL renderer.
 type openGLRenderer struct {
-	glfwMonitors   []*glfw.Monitor
-	displays       []image.Rectangle
-	mousePressCb   func(index int, pos image.Rectangle)
-	mouseReleaseCb func(index int, pos image.Rectangle)
-	windows        []*glfw.Window
-	keyCb          func(Release bool, Index, Key int)
-	darkerTextures []*rgbaImage
-	normalTextures []*glhf.Texture
-	shaders        []*glhf.Shader
+	glfwMonitors      []*glfw.Monitor
+	displays          []image.Rectangle
+	mousePressCb      func(index int, pos image.Rectangle)
+	mouseReleaseCb    func(index int, pos image.Rectangle)
+	windows           []*glfw.Window
+	keyCb             func(Release bool, Index, Key int)
+	normalTextures    []*glhf.Texture
+	shaders           []*glhf.Shader
+	contentScaleX     []float32
+	contentScaleY     []float32
+	displaysChangedCb func(newDisplays []image.Rectangle)
+	eventMode         EventMode
+	monitorsDirty     bool
+}
+
+// GetContentScale is used to get the content (DPI) scale of a display, i.e. how many
+// framebuffer pixels make up one logical/screen-coordinate pixel. This is 1, 1 on a
+// standard-DPI display and e.g. 2, 2 on a Retina display. Returns 1, 1 if index belongs to
+// a display that was torn down for a monitor reload and not yet rebuilt.
+func (r *openGLRenderer) GetContentScale(index int) (float32, float32) {
+	if index < 0 || index >= len(r.contentScaleX) {
+		return 1, 1
+	}
+	return r.contentScaleX[index], r.contentScaleY[index]
 }
 
 // ShouldClose is used to say windows should close.
 func (r *openGLRenderer) ShouldClose() {
-	r.windows[0].SetShouldClose(true)
+	if len(r.windows) > 0 {
+		r.windows[0].SetShouldClose(true)
+	}
+
+	// Wake a blocked PollEvents (EventModeWait) so the selector notices ShouldClose
+	// straight away instead of waiting for the next event or timeout.
+	r.WakeEventLoop()
 }
 
-// WindowShouldClose is used to check if a window should close.
+// WakeEventLoop posts an empty event to unblock a PollEvents call that is currently
+// waiting in EventModeWait. Safe to call from any goroutine - hotkey handlers and the
+// tray menu both dismiss the selector from outside the main thread.
+func (r *openGLRenderer) WakeEventLoop() {
+	mainthread.ExecMainThread(glfw.PostEmptyEvent)
+}
+
+// SetEventMode selects whether PollEvents spins (EventModePoll) or blocks until the next
+// event/timeout (EventModeWait).
+func (r *openGLRenderer) SetEventMode(mode EventMode) {
+	r.eventMode = mode
+}
+
+// WindowShouldClose is used to check if a window should close. A display torn down for a
+// monitor hot-plug reload and not yet rebuilt is reported as closed, rather than panicking
+// on a stale index.
 func (r *openGLRenderer) WindowShouldClose(index int) bool {
+	if index < 0 || index >= len(r.windows) {
+		return true
+	}
 	return r.windows[index].ShouldClose()
 }
 
@@ -65,16 +99,91 @@ func (r *openGLRenderer) SetMouseReleaseCallback(Function func(index int, pos im
 	r.mouseReleaseCb = Function
 }
 
-// PollEvents is used to poll for events.
+// SetDisplaysChangedCallback registers a callback invoked whenever a monitor is plugged,
+// unplugged, or changes resolution mid-session. The renderer tears down its own windows
+// and GL resources first, then hands the caller the fresh display geometry so it can
+// re-capture screenshots and call Init again.
+func (r *openGLRenderer) SetDisplaysChangedCallback(Function func(newDisplays []image.Rectangle)) {
+	r.displaysChangedCb = Function
+}
+
+// teardownForReload destroys all current windows/GL resources. It is used when the
+// monitor topology changes so Init can cleanly rebuild everything for the new layout,
+// rather than trying to patch stale glfwMonitors/windows indices in place. Must only be
+// called from PollEvents, never from inside the glfw.SetMonitorCallback itself - GLFW
+// invokes that callback from within PollEvents/WaitEventsTimeout, which is already
+// running inside mainthread.ExecMainThread, and nesting another ExecMainThread call in
+// there would either deadlock or re-enter the main-thread worker depending on its
+// implementation.
+func (r *openGLRenderer) teardownForReload() {
+	mainthread.ExecMainThread(func() {
+		for _, v := range r.windows {
+			v.MakeContextCurrent()
+			v.Destroy()
+		}
+	})
+	r.glfwMonitors = nil
+	r.windows = nil
+	r.shaders = nil
+	r.normalTextures = nil
+	r.contentScaleX = nil
+	r.contentScaleY = nil
+}
+
+// currentDisplays builds the Displays slice Init expects from whatever monitors GLFW can
+// currently see.
+func currentDisplays() []image.Rectangle {
+	var out []image.Rectangle
+	mainthread.ExecMainThread(func() {
+		for _, m := range glfw.GetMonitors() {
+			x, y := m.GetPos()
+			mode := m.GetVideoMode()
+			out = append(out, image.Rect(x, y, x+mode.Width, y+mode.Height))
+		}
+	})
+	return out
+}
+
+// PollEvents is used to poll for events. A monitor hot-plug detected during this call is
+// handled once PollEvents itself has returned, so teardownForReload and the caller's
+// displaysChangedCb never run nested inside the ExecMainThread call GLFW's callback fired
+// from - and so that nothing can observe the torn-down, not-yet-rebuilt renderer state in
+// between (the callback only flags monitorsDirty; it does no GLFW/Go-level teardown of
+// its own).
 func (r *openGLRenderer) PollEvents() {
-	mainthread.ExecMainThread(glfw.PollEvents)
+	mainthread.ExecMainThread(func() {
+		if r.eventMode == EventModeWait {
+			glfw.WaitEventsTimeout(0.016)
+			return
+		}
+		glfw.PollEvents()
+	})
+
+	if r.monitorsDirty {
+		r.monitorsDirty = false
+		r.teardownForReload()
+		if r.displaysChangedCb != nil {
+			r.displaysChangedCb(currentDisplays())
+		}
+	}
 }
 
 // Init is used to initialise the renderer.
-func (r *openGLRenderer) Init(Displays []image.Rectangle, DarkerScreenshots, Screenshots []*image.RGBA) {
+func (r *openGLRenderer) Init(Displays []image.Rectangle, Screenshots []*image.RGBA) {
 	// Set displays.
 	r.displays = Displays
 
+	// Re-registering this on every Init is harmless - GLFW just swaps out the previous
+	// callback. It fires whenever a monitor is connected, disconnected, or its resolution
+	// changes, which would otherwise panic on the next PollEvents/render into stale
+	// geometry. It only flags the change; PollEvents does the actual teardown once it is
+	// no longer running nested inside this same GLFW dispatch (see PollEvents).
+	mainthread.ExecMainThread(func() {
+		glfw.SetMonitorCallback(func(_ *glfw.Monitor, _ glfw.MonitorEvent) {
+			r.monitorsDirty = true
+		})
+	})
+
 	// Remap the monitors to the order of the "displays" array.
 	var GLFWMonitorsUnordered []*glfw.Monitor
 	mainthread.ExecMainThread(func() {
@@ -99,8 +208,9 @@ func (r *openGLRenderer) Init(Displays []image.Rectangle, DarkerScreenshots, Scr
 
 	// Defines all needed OpenGL definitions.
 	r.shaders = make([]*glhf.Shader, len(r.displays))
-	r.darkerTextures = make([]*rgbaImage, len(r.displays))
 	r.normalTextures = make([]*glhf.Texture, len(r.displays))
+	r.contentScaleX = make([]float32, len(r.displays))
+	r.contentScaleY = make([]float32, len(r.displays))
 
 	// Make a window on each display.
 	r.windows = make([]*glfw.Window, len(r.glfwMonitors))
@@ -154,23 +264,47 @@ func (r *openGLRenderer) Init(Displays []image.Rectangle, DarkerScreenshots, Scr
 				Window.SetMonitor(r.glfwMonitors[i], 0, 0, width, height, refreshRate)
 			}
 
-			// Remember these for later.
+			// Work out the content scale for this display, so textures/mouse coordinates
+			// can be mapped from logical screen coordinates to framebuffer pixels. This
+			// covers mixed-DPI setups, e.g. a Retina built-in display next to a 1080p
+			// external one.
+			scaleX, scaleY := r.glfwMonitors[i].GetContentScale()
+			if fbWidth, fbHeight := Window.GetFramebufferSize(); fbWidth > 0 && fbHeight > 0 && width > 0 && height > 0 {
+				// The framebuffer/window ratio is authoritative if it disagrees with the
+				// monitor's reported content scale.
+				scaleX = float32(fbWidth) / float32(width)
+				scaleY = float32(fbHeight) / float32(height)
+			}
+			r.contentScaleX[i] = scaleX
+			r.contentScaleY[i] = scaleY
+
+			// Remember this for later.
 			index := i
-			DisplayPos := v
 
 			// Sets the mouse button handler.
-			Window.SetMouseButtonCallback(func(_ *glfw.Window, button glfw.MouseButton, action glfw.Action, _ glfw.ModifierKey) {
+			Window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, _ glfw.ModifierKey) {
 				if button != glfw.MouseButton1 {
 					return
 				}
 
+				// Scale the cursor position from logical screen coordinates to framebuffer
+				// pixels before forwarding it, so callers get pixel-accurate coordinates
+				// regardless of this display's content scale. This is texture-local (i.e.
+				// relative to this display's own framebuffer), not a global desktop
+				// coordinate - index already tells the caller which display it's on, so
+				// there is no display-origin offset to add.
+				cx, cy := w.GetCursorPos()
+				px := int(cx * float64(r.contentScaleX[index]))
+				py := int(cy * float64(r.contentScaleY[index]))
+				pos := image.Rect(px, py, px, py)
+
 				if action == glfw.Press {
 					if r.mousePressCb != nil {
-						r.mousePressCb(index, DisplayPos)
+						r.mousePressCb(index, pos)
 					}
 				} else if action == glfw.Release {
 					if r.mouseReleaseCb != nil {
-						r.mouseReleaseCb(index, DisplayPos)
+						r.mouseReleaseCb(index, pos)
 					}
 				}
 			})
@@ -182,24 +316,23 @@ func (r *openGLRenderer) Init(Displays []image.Rectangle, DarkerScreenshots, Scr
 				}
 			})
 
-			// Creates all required OpenGL definitions.
+			// Creates all required OpenGL definitions. Dimming/blurring is now done by the
+			// fragment shader at draw time (see GetDimmedTexture), so the only uniforms we
+			// need are uDim and uBlurRadius.
 			s, err := glhf.NewShader(glhf.AttrFormat{
 				{Name: "position", Type: glhf.Vec2},
 				{Name: "texture", Type: glhf.Vec2},
-			}, glhf.AttrFormat{}, vertexShader, fragmentShader)
+			}, glhf.AttrFormat{
+				{Name: "uDim", Type: glhf.Float},
+				{Name: "uBlurRadius", Type: glhf.Float},
+			}, vertexShader, fragmentShader)
 			if err != nil {
 				panic(err)
 			}
 			r.shaders[i] = s
 
-			// Creates the texture.
-			r.darkerTextures[i] = &rgbaImage{
-				data: DarkerScreenshots[i].Pix,
-				w:    DarkerScreenshots[i].Bounds().Dx(),
-				h:    DarkerScreenshots[i].Bounds().Dy(),
-			}
-
-			// Creates the brighter texture.
+			// Uploads the screenshot exactly once - there is no separate darker copy
+			// anymore.
 			t := glhf.NewTexture(
 				Screenshots[i].Bounds().Dx(),
 				Screenshots[i].Bounds().Dy(),
@@ -212,7 +345,9 @@ func (r *openGLRenderer) Init(Displays []image.Rectangle, DarkerScreenshots, Scr
 }
 
 type openGlTexture struct {
-	texture *glhf.Texture
+	texture    *glhf.Texture
+	dim        float32
+	blurRadius float32
 }
 
 // Begin defines the start of texture modifications.
@@ -243,19 +378,24 @@ func (t *openGlTexture) GetWidthHeight() (int, int) {
 	return w, h
 }
 
-// GetDarkerTexture is used to get the darker texture.
-func (r *openGLRenderer) GetDarkerTexture(index int) Texture {
-	var x *glhf.Texture
-	mainthread.ExecMainThread(func() {
-		t := r.darkerTextures[index]
-		x = glhf.NewTexture(t.w, t.h, true, t.data)
-		runtime.GC()
-	})
-	return &openGlTexture{texture: x}
+// GetDimmedTexture is used to get a handle to the screenshot texture that draws dimmed by
+// the given factor (1 = full brightness, 0 = black) and, if blurRadius is above zero,
+// box-blurred by that many texels. It references the same underlying GL texture that was
+// uploaded in Init - no extra copy is made, the dimming/blurring happens in the fragment
+// shader at draw time. Returns nil if index belongs to a display that was torn down for a
+// monitor reload and not yet rebuilt.
+func (r *openGLRenderer) GetDimmedTexture(index int, dim, blurRadius float32) Texture {
+	if index < 0 || index >= len(r.normalTextures) {
+		return nil
+	}
+	return &openGlTexture{texture: r.normalTextures[index], dim: dim, blurRadius: blurRadius}
 }
 
 // GetNormalTexturePixels is used to get the normal texture pixels.
 func (r *openGLRenderer) GetNormalTexturePixels(index, Left, Top, W, H int) []uint8 {
+	if index < 0 || index >= len(r.normalTextures) {
+		return nil
+	}
 	var x []uint8
 	mainthread.ExecMainThread(func() {
 		t := r.normalTextures[index]
@@ -267,9 +407,14 @@ func (r *openGLRenderer) GetNormalTexturePixels(index, Left, Top, W, H int) []ui
 	return x
 }
 
-// RenderTexture is used to render a texture to the screen.
+// RenderTexture is used to render a texture to the screen. A no-op if index belongs to a
+// display that was torn down for a monitor reload and not yet rebuilt.
 func (r *openGLRenderer) RenderTexture(index int, t Texture) {
-	glt := t.(*openGlTexture).texture
+	if index < 0 || index >= len(r.windows) {
+		return
+	}
+	ogt := t.(*openGlTexture)
+	glt := ogt.texture
 	mainthread.ExecMainThread(func() {
 		// Get the window.
 		window := r.windows[index]
@@ -297,8 +442,10 @@ func (r *openGLRenderer) RenderTexture(index int, t Texture) {
 		// Get the shader.
 		shader := r.shaders[index]
 
-		// Render everything.
+		// Render everything, passing the dim/blur uniforms through to the fragment shader.
 		shader.Begin()
+		shader.SetUniformAttr(0, ogt.dim)
+		shader.SetUniformAttr(1, ogt.blurRadius)
 		glt.Begin()
 		slice.Begin()
 		slice.Draw()
@@ -314,14 +461,26 @@ func (r *openGLRenderer) RenderTexture(index int, t Texture) {
 	})
 }
 
-// RendererInit is used to initialise the renderer.
-func (openGLRenderer) RendererInit() {
-	err := glfw.Init()
-	if err != nil {
-		panic(err)
+// RendererInit is used to initialise the renderer. Rather than panicking, failures are
+// returned so the caller can fall back to the software renderer or show a dialog instead
+// of crashing the whole app.
+func (openGLRenderer) RendererInit() error {
+	installGLFWErrorCallback()
+
+	if err := glfw.Init(); err != nil {
+		return fmt.Errorf("glfw init failed: %w", err)
 	}
-	err = gl.Init()
-	if err != nil {
-		panic(err)
+	if err := gl.Init(); err != nil {
+		return fmt.Errorf("gl init failed: %w", err)
 	}
+
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetContext("opengl", map[string]interface{}{
+			"version":  gl.GoStr(gl.GetString(gl.VERSION)),
+			"renderer": gl.GoStr(gl.GetString(gl.RENDERER)),
+			"vendor":   gl.GoStr(gl.GetString(gl.VENDOR)),
+		})
+	})
+
+	return nil
 }
\ No newline at end of file
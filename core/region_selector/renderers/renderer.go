@@ -0,0 +1,46 @@
+package renderers
+
+import "image"
+
+// EventMode controls how Renderer.PollEvents waits for input.
+type EventMode int
+
+const (
+	// EventModePoll returns from PollEvents immediately, spinning the poll loop as fast as
+	// the caller drives it.
+	EventModePoll EventMode = iota
+	// EventModeWait blocks in PollEvents until an event arrives or a short timeout elapses,
+	// cutting idle CPU usage on the selector to near zero on battery.
+	EventModeWait
+)
+
+// Texture is the interface implemented by a texture that a Renderer can upload pixels to
+// and draw to the screen.
+type Texture interface {
+	Begin()
+	End()
+	SetPixels(X, Y, Width, Height int, Pix []byte)
+	GetWidthHeight() (int, int)
+}
+
+// Renderer is the interface implemented by each backend the region selector can draw
+// with. openGLRenderer is the GPU-accelerated implementation; softwareRenderer is the
+// fallback used when no GL 3.3 context is available.
+type Renderer interface {
+	Init(Displays []image.Rectangle, Screenshots []*image.RGBA)
+	RenderTexture(index int, t Texture)
+	GetDimmedTexture(index int, dim, blurRadius float32) Texture
+	GetContentScale(index int) (float32, float32)
+	GetNormalTexturePixels(index, Left, Top, W, H int) []uint8
+	PollEvents()
+	SetEventMode(mode EventMode)
+	WakeEventLoop()
+	SetKeyCallback(Function func(Release bool, index, key int))
+	SetMousePressCallback(Function func(index int, pos image.Rectangle))
+	SetMouseReleaseCallback(Function func(index int, pos image.Rectangle))
+	SetDisplaysChangedCallback(Function func(newDisplays []image.Rectangle))
+	DestroyAll()
+	ShouldClose()
+	WindowShouldClose(index int) bool
+	RendererInit() error
+}
@@ -0,0 +1,55 @@
+package renderers
+
+// RendererTier identifies which of the three Renderer implementations NewRenderer picked.
+type RendererTier int
+
+const (
+	// RendererTierOpenGL is the GPU-accelerated core-profile renderer.
+	RendererTierOpenGL RendererTier = iota
+	// RendererTierSoftware is the legacy/fixed-function GL renderer, used when a display
+	// server is available but a GL 3.3 core context isn't.
+	RendererTierSoftware
+	// RendererTierHeadless is the last resort used when no display server is available at
+	// all, e.g. CI runners and SSH-only boxes.
+	RendererTierHeadless
+)
+
+// ForceSoftwareRenderer is a config-driven override which skips straight to the software
+// fallback without even attempting to bring up an OpenGL 3.3 context. Intended for users
+// who have already confirmed their machine can't do hardware-accelerated rendering (VMs,
+// remote desktops) and don't want to pay the cost of a failed init on every launch.
+var ForceSoftwareRenderer = false
+
+// ForceHeadlessRenderer is a config-driven override which skips straight to the headless
+// fallback. Intended for automated/CI environments that are known to have no display
+// server, so capture flows don't pay the cost of two failed GLFW inits on every launch.
+var ForceHeadlessRenderer = false
+
+// NewRenderer picks the best Renderer for this machine. It tries the GPU-accelerated
+// openGLRenderer first, falls back to the legacy-GL softwareRenderer if RendererInit
+// returns an error, and falls back further to headlessRenderer if that also fails (e.g. no
+// display server at all), unless ForceSoftwareRenderer or ForceHeadlessRenderer is set.
+func NewRenderer() (r Renderer, tier RendererTier) {
+	if ForceHeadlessRenderer {
+		return &headlessRenderer{}, RendererTierHeadless
+	}
+	if ForceSoftwareRenderer {
+		sw := &softwareRenderer{}
+		if err := sw.RendererInit(); err == nil {
+			return sw, RendererTierSoftware
+		}
+		return &headlessRenderer{}, RendererTierHeadless
+	}
+
+	gl := &openGLRenderer{}
+	if err := gl.RendererInit(); err == nil {
+		return gl, RendererTierOpenGL
+	}
+
+	sw := &softwareRenderer{}
+	if err := sw.RendererInit(); err == nil {
+		return sw, RendererTierSoftware
+	}
+
+	return &headlessRenderer{}, RendererTierHeadless
+}
@@ -0,0 +1,51 @@
+package renderers
+
+// vertexShader just forwards the texture coordinates through to the fragment stage.
+const vertexShader = `
+#version 330 core
+
+in vec2 position;
+in vec2 texture;
+
+out vec2 fragTexCoord;
+
+void main() {
+	gl_Position = vec4(position, 0.0, 1.0);
+	fragTexCoord = texture;
+}
+`
+
+// fragmentShader dims (and optionally blurs) the screenshot texture at draw time, so only
+// one copy of it ever needs to live on the GPU.
+const fragmentShader = `
+#version 330 core
+
+in vec2 fragTexCoord;
+
+uniform sampler2D tex;
+uniform float uDim;
+uniform float uBlurRadius;
+
+out vec4 outColor;
+
+void main() {
+	vec4 c;
+	if (uBlurRadius > 0.0) {
+		// A small box blur. uBlurRadius is in texels, not pixels, so it scales with
+		// whatever resolution the screenshot texture was uploaded at.
+		vec2 texel = 1.0 / vec2(textureSize(tex, 0));
+		const int kTaps = 4;
+		vec4 sum = vec4(0.0);
+		for (int x = -kTaps; x <= kTaps; x++) {
+			for (int y = -kTaps; y <= kTaps; y++) {
+				vec2 offset = vec2(float(x), float(y)) * texel * (uBlurRadius / float(kTaps));
+				sum += texture(tex, fragTexCoord + offset);
+			}
+		}
+		c = sum / float((2 * kTaps + 1) * (2 * kTaps + 1));
+	} else {
+		c = texture(tex, fragTexCoord);
+	}
+	outColor = vec4(c.rgb * uDim, c.a);
+}
+`
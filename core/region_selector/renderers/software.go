@@ -0,0 +1,414 @@
+package renderers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/magiccap/MagicCap/core/mainthread"
+)
+
+// softwareRenderer is a fallback used on machines that can open a window and a GLFW/GL
+// context but can't get OpenGL 3.3 core profile out of it (older iGPUs, some remote
+// desktops). It reuses the same GLFW windowing layer as openGLRenderer, but asks for
+// whatever GL context the driver can actually provide (down to the software Mesa llvmpipe
+// rasteriser) and draws with immediate-mode calls instead of the core-profile shader
+// pipeline, at the cost of shader-based dimming/blurring. It still needs a display server
+// to create a window against - on a genuinely headless machine (no X11/Wayland/Cocoa, CI
+// runners, SSH-only boxes) glfw.Init itself fails here exactly as it does in
+// openGLRenderer, so that case falls through to headlessRenderer instead.
+type softwareRenderer struct {
+	glfwMonitors   []*glfw.Monitor
+	displays       []image.Rectangle
+	mousePressCb   func(index int, pos image.Rectangle)
+	mouseReleaseCb func(index int, pos image.Rectangle)
+	windows        []*glfw.Window
+	keyCb          func(Release bool, Index, Key int)
+	normalTextures []uint32
+	contentScaleX  []float32
+	contentScaleY  []float32
+
+	displaysChangedCb func(newDisplays []image.Rectangle)
+	eventMode         EventMode
+	monitorsDirty     bool
+}
+
+// GetContentScale is used to get the content (DPI) scale of a display. See the matching
+// doc comment on openGLRenderer.GetContentScale.
+func (r *softwareRenderer) GetContentScale(index int) (float32, float32) {
+	if index < 0 || index >= len(r.contentScaleX) {
+		return 1, 1
+	}
+	return r.contentScaleX[index], r.contentScaleY[index]
+}
+
+// ShouldClose is used to say windows should close.
+func (r *softwareRenderer) ShouldClose() {
+	if len(r.windows) > 0 {
+		r.windows[0].SetShouldClose(true)
+	}
+	r.WakeEventLoop()
+}
+
+// WakeEventLoop posts an empty event to unblock a PollEvents call that is currently
+// waiting in EventModeWait. See the matching doc comment on openGLRenderer.WakeEventLoop.
+func (r *softwareRenderer) WakeEventLoop() {
+	mainthread.ExecMainThread(glfw.PostEmptyEvent)
+}
+
+// SetEventMode selects whether PollEvents spins (EventModePoll) or blocks until the next
+// event/timeout (EventModeWait).
+func (r *softwareRenderer) SetEventMode(mode EventMode) {
+	r.eventMode = mode
+}
+
+// WindowShouldClose is used to check if a window should close. A display torn down for a
+// monitor hot-plug reload and not yet rebuilt is reported as closed, rather than panicking
+// on a stale index.
+func (r *softwareRenderer) WindowShouldClose(index int) bool {
+	if index < 0 || index >= len(r.windows) {
+		return true
+	}
+	return r.windows[index].ShouldClose()
+}
+
+// DestroyAll is used to destroy all of the windows.
+func (r *softwareRenderer) DestroyAll() {
+	mainthread.ExecMainThread(func() {
+		for i, v := range r.windows {
+			v.MakeContextCurrent()
+			gl.DeleteTextures(1, &r.normalTextures[i])
+			v.Destroy()
+		}
+	})
+}
+
+// SetKeyCallback is used to handle key callbacks.
+func (r *softwareRenderer) SetKeyCallback(Function func(Release bool, index, key int)) {
+	r.keyCb = Function
+}
+
+// SetMousePressCallback is used to set a mouse callback for when it is pressed.
+func (r *softwareRenderer) SetMousePressCallback(Function func(index int, pos image.Rectangle)) {
+	r.mousePressCb = Function
+}
+
+// SetMouseReleaseCallback is used to set a mouse callback for when it is released.
+func (r *softwareRenderer) SetMouseReleaseCallback(Function func(index int, pos image.Rectangle)) {
+	r.mouseReleaseCb = Function
+}
+
+// SetDisplaysChangedCallback registers a callback invoked whenever a monitor is plugged,
+// unplugged, or changes resolution mid-session. See the matching doc comment on
+// openGLRenderer.SetDisplaysChangedCallback.
+func (r *softwareRenderer) SetDisplaysChangedCallback(Function func(newDisplays []image.Rectangle)) {
+	r.displaysChangedCb = Function
+}
+
+// teardownForReload destroys all current windows/GL resources. See the matching doc
+// comment on openGLRenderer.teardownForReload - must only be called from PollEvents, never
+// from inside glfw.SetMonitorCallback itself.
+func (r *softwareRenderer) teardownForReload() {
+	mainthread.ExecMainThread(func() {
+		for i, v := range r.windows {
+			v.MakeContextCurrent()
+			gl.DeleteTextures(1, &r.normalTextures[i])
+			v.Destroy()
+		}
+	})
+	r.glfwMonitors = nil
+	r.windows = nil
+	r.normalTextures = nil
+	r.contentScaleX = nil
+	r.contentScaleY = nil
+}
+
+// PollEvents is used to poll for events. See the matching doc comment on
+// openGLRenderer.PollEvents.
+func (r *softwareRenderer) PollEvents() {
+	mainthread.ExecMainThread(func() {
+		if r.eventMode == EventModeWait {
+			glfw.WaitEventsTimeout(0.016)
+			return
+		}
+		glfw.PollEvents()
+	})
+
+	if r.monitorsDirty {
+		r.monitorsDirty = false
+		r.teardownForReload()
+		if r.displaysChangedCb != nil {
+			r.displaysChangedCb(currentDisplays())
+		}
+	}
+}
+
+// uploadTexture creates (or re-creates) a legacy GL texture from a RGBA image.
+func uploadTexture(img *image.RGBA) uint32 {
+	var id uint32
+	gl.GenTextures(1, &id)
+	gl.BindTexture(gl.TEXTURE_2D, id)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	b := img.Bounds()
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(b.Dx()), int32(b.Dy()), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	return id
+}
+
+// Init is used to initialise the renderer.
+func (r *softwareRenderer) Init(Displays []image.Rectangle, Screenshots []*image.RGBA) {
+	// Set displays.
+	r.displays = Displays
+
+	// See the matching comment in openGLRenderer.Init.
+	mainthread.ExecMainThread(func() {
+		glfw.SetMonitorCallback(func(_ *glfw.Monitor, _ glfw.MonitorEvent) {
+			r.monitorsDirty = true
+		})
+	})
+
+	// Remap the monitors to the order of the "displays" array.
+	var GLFWMonitorsUnordered []*glfw.Monitor
+	mainthread.ExecMainThread(func() {
+		GLFWMonitorsUnordered = glfw.GetMonitors()
+	})
+	r.glfwMonitors = make([]*glfw.Monitor, len(GLFWMonitorsUnordered))
+	for _, Monitor := range GLFWMonitorsUnordered {
+		x, y := Monitor.GetPos()
+		Matches := false
+		for i, v := range Displays {
+			if v.Bounds().Min.X == x && v.Bounds().Min.Y == y {
+				// This is the correct display.
+				r.glfwMonitors[i] = Monitor
+				Matches = true
+				break
+			}
+		}
+		if !Matches {
+			panic(errors.New("cannot find matching glfw display"))
+		}
+	}
+
+	// Defines all needed definitions.
+	r.normalTextures = make([]uint32, len(r.displays))
+	r.contentScaleX = make([]float32, len(r.displays))
+	r.contentScaleY = make([]float32, len(r.displays))
+
+	// Make a window on each display.
+	r.windows = make([]*glfw.Window, len(r.glfwMonitors))
+	var FirstWindow *glfw.Window
+	mainthread.ExecMainThread(func() {
+		for i, v := range r.displays {
+			// Creates the window. Unlike openGLRenderer, no GL version is requested here -
+			// GLFW is left to hand back whatever context the driver supports, including a
+			// software rasteriser.
+			glfw.WindowHint(glfw.CenterCursor, glfw.False)
+			glfw.WindowHint(glfw.Decorated, glfw.False)
+			glfw.WindowHint(glfw.FocusOnShow, glfw.True)
+			glfw.WindowHint(glfw.Floating, glfw.True)
+			glfw.WindowHint(glfw.AutoIconify, glfw.False)
+			glfw.WindowHint(glfw.Resizable, glfw.False)
+
+			monitor := r.glfwMonitors[i]
+			if runtime.GOOS == "linux" {
+				// See the matching comment in openGLRenderer.Init.
+				monitor = nil
+			}
+			width := v.Max.X - v.Min.X
+			height := v.Max.Y - v.Min.Y
+			Window, err := glfw.CreateWindow(width, height, "MagicCap Region Selector", monitor, FirstWindow)
+			if err != nil {
+				panic(err)
+			}
+			if FirstWindow == nil {
+				FirstWindow = Window
+			}
+			r.windows[i] = Window
+			Window.MakeContextCurrent()
+			if runtime.GOOS == "linux" {
+				refreshRate := r.glfwMonitors[i].GetVideoMode().RefreshRate
+				Window.SetMonitor(r.glfwMonitors[i], 0, 0, width, height, refreshRate)
+			}
+
+			// See the matching comment in openGLRenderer.Init.
+			scaleX, scaleY := r.glfwMonitors[i].GetContentScale()
+			if fbWidth, fbHeight := Window.GetFramebufferSize(); fbWidth > 0 && fbHeight > 0 && width > 0 && height > 0 {
+				scaleX = float32(fbWidth) / float32(width)
+				scaleY = float32(fbHeight) / float32(height)
+			}
+			r.contentScaleX[i] = scaleX
+			r.contentScaleY[i] = scaleY
+
+			// Remember this for later.
+			index := i
+
+			// Sets the mouse button handler.
+			Window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, _ glfw.ModifierKey) {
+				if button != glfw.MouseButton1 {
+					return
+				}
+				// See the matching comment in openGLRenderer.Init - this is texture-local,
+				// not a global desktop coordinate.
+				cx, cy := w.GetCursorPos()
+				px := int(cx * float64(r.contentScaleX[index]))
+				py := int(cy * float64(r.contentScaleY[index]))
+				pos := image.Rect(px, py, px, py)
+				if action == glfw.Press {
+					if r.mousePressCb != nil {
+						r.mousePressCb(index, pos)
+					}
+				} else if action == glfw.Release {
+					if r.mouseReleaseCb != nil {
+						r.mouseReleaseCb(index, pos)
+					}
+				}
+			})
+
+			// Sets the key handler.
+			Window.SetKeyCallback(func(_ *glfw.Window, key glfw.Key, _ int, action glfw.Action, _ glfw.ModifierKey) {
+				if r.keyCb != nil {
+					r.keyCb(action == glfw.Release, index, int(key))
+				}
+			})
+
+			// Only the bright screenshot is uploaded - dimming is applied at draw time via
+			// gl.Color4f, which the fixed-function pipeline modulates the texture by.
+			r.normalTextures[i] = uploadTexture(Screenshots[i])
+		}
+	})
+}
+
+// softwareTexture wraps a legacy GL texture drawn with immediate-mode calls.
+type softwareTexture struct {
+	id   uint32
+	w, h int
+	dim  float32
+}
+
+// Begin defines the start of texture modifications.
+func (t *softwareTexture) Begin() {
+	mainthread.ExecMainThread(func() {
+		gl.BindTexture(gl.TEXTURE_2D, t.id)
+	})
+}
+
+// End defines the end of texture modifications.
+func (t *softwareTexture) End() {
+	mainthread.ExecMainThread(func() {
+		gl.BindTexture(gl.TEXTURE_2D, 0)
+	})
+}
+
+// SetPixels is used to set the pixels.
+func (t *softwareTexture) SetPixels(X, Y, Width, Height int, Pix []byte) {
+	mainthread.ExecMainThread(func() {
+		gl.BindTexture(gl.TEXTURE_2D, t.id)
+		gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(X), int32(Y), int32(Width), int32(Height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(Pix))
+	})
+}
+
+// GetWidthHeight is used to get the width/height.
+func (t *softwareTexture) GetWidthHeight() (int, int) {
+	return t.w, t.h
+}
+
+// GetDimmedTexture is used to get a handle to the screenshot texture that draws dimmed by
+// the given factor (1 = full brightness, 0 = black). It references the same underlying GL
+// texture uploaded in Init - the dimming is applied in RenderTexture via gl.Color4f rather
+// than a second copy of the pixels. blurRadius is accepted for interface parity with
+// openGLRenderer but ignored - the fixed-function pipeline has no equivalent to the
+// fragment-shader box blur, and this renderer is already the degraded fallback. Returns nil
+// if index belongs to a display that was torn down for a monitor reload and not yet
+// rebuilt.
+func (r *softwareRenderer) GetDimmedTexture(index int, dim, blurRadius float32) Texture {
+	if index < 0 || index >= len(r.normalTextures) {
+		return nil
+	}
+	b := r.displays[index]
+	return &softwareTexture{id: r.normalTextures[index], dim: dim, w: b.Dx(), h: b.Dy()}
+}
+
+// GetNormalTexturePixels is used to get the normal texture pixels.
+func (r *softwareRenderer) GetNormalTexturePixels(index, Left, Top, W, H int) []uint8 {
+	if index < 0 || index >= len(r.normalTextures) {
+		return nil
+	}
+	x := make([]uint8, W*H*4)
+	mainthread.ExecMainThread(func() {
+		gl.BindTexture(gl.TEXTURE_2D, r.normalTextures[index])
+		// GetTexImage always reads back the whole texture, so read it in full and crop.
+		var texW, texH int32
+		gl.GetTexLevelParameteriv(gl.TEXTURE_2D, 0, gl.TEXTURE_WIDTH, &texW)
+		gl.GetTexLevelParameteriv(gl.TEXTURE_2D, 0, gl.TEXTURE_HEIGHT, &texH)
+		full := make([]uint8, int(texW)*int(texH)*4)
+		gl.GetTexImage(gl.TEXTURE_2D, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(full))
+		for y := 0; y < H; y++ {
+			srcOff := ((Top+y)*int(texW) + Left) * 4
+			copy(x[y*W*4:(y+1)*W*4], full[srcOff:srcOff+W*4])
+		}
+	})
+	return x
+}
+
+// RenderTexture is used to render a texture to the screen. A no-op if index belongs to a
+// display that was torn down for a monitor reload and not yet rebuilt.
+func (r *softwareRenderer) RenderTexture(index int, t Texture) {
+	if index < 0 || index >= len(r.windows) {
+		return
+	}
+	glt := t.(*softwareTexture)
+	mainthread.ExecMainThread(func() {
+		window := r.windows[index]
+		window.MakeContextCurrent()
+
+		gl.Enable(gl.TEXTURE_2D)
+		gl.BindTexture(gl.TEXTURE_2D, glt.id)
+		gl.ClearColor(1, 1, 1, 1)
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+
+		// The fixed-function pipeline's default GL_MODULATE texture env multiplies the
+		// texture by the current color, so this dims the screenshot without a second copy.
+		gl.Color4f(glt.dim, glt.dim, glt.dim, 1)
+
+		gl.Begin(gl.QUADS)
+		gl.TexCoord2f(0, 1)
+		gl.Vertex2f(-1, -1)
+		gl.TexCoord2f(1, 1)
+		gl.Vertex2f(1, -1)
+		gl.TexCoord2f(1, 0)
+		gl.Vertex2f(1, 1)
+		gl.TexCoord2f(0, 0)
+		gl.Vertex2f(-1, 1)
+		gl.End()
+
+		window.SwapBuffers()
+		runtime.GC()
+	})
+}
+
+// RendererInit is used to initialise the renderer. Rather than panicking, failures are
+// returned so the caller can fall back to headlessRenderer.
+func (softwareRenderer) RendererInit() error {
+	installGLFWErrorCallback()
+
+	if err := glfw.Init(); err != nil {
+		return fmt.Errorf("glfw init failed: %w", err)
+	}
+	if err := gl.Init(); err != nil {
+		return fmt.Errorf("gl init failed: %w", err)
+	}
+
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetContext("opengl", map[string]interface{}{
+			"version":  gl.GoStr(gl.GetString(gl.VERSION)),
+			"renderer": gl.GoStr(gl.GetString(gl.RENDERER)),
+			"vendor":   gl.GoStr(gl.GetString(gl.VENDOR)),
+		})
+	})
+
+	return nil
+}